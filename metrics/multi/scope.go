@@ -0,0 +1,231 @@
+// Package multi implements a metrics.Scope that fans every emission out
+// to a fixed set of underlying Scopes, so a caller can, for example,
+// report to Prometheus and to StatsD through a single Scope.
+package multi
+
+import (
+	"time"
+
+	"github.com/gottingen/kmetrics/metrics"
+)
+
+// scope implements metrics.Scope by forwarding every call to a fixed
+// list of underlying scopes.
+type scope struct {
+	scopes []metrics.Scope
+}
+
+// NewMultiScope returns a Scope that fans every emission out to each of
+// scopes. Tagged and SubScope are propagated to every child scope, and
+// Capabilities() reports Reporting() true if any child can report and
+// Tagging() true only if every child supports tagging.
+func NewMultiScope(scopes ...metrics.Scope) metrics.Scope {
+	cp := make([]metrics.Scope, len(scopes))
+	copy(cp, scopes)
+	return &scope{scopes: cp}
+}
+
+func (s *scope) Counter(name string) metrics.Counter {
+	counters := make([]metrics.Counter, len(s.scopes))
+	for i, child := range s.scopes {
+		counters[i] = child.Counter(name)
+	}
+	return multiCounter(counters)
+}
+
+func (s *scope) Gauge(name string) metrics.Gauge {
+	gauges := make([]metrics.Gauge, len(s.scopes))
+	for i, child := range s.scopes {
+		gauges[i] = child.Gauge(name)
+	}
+	return multiGauge(gauges)
+}
+
+func (s *scope) IntegerGauge(name string) metrics.IntegerGauge {
+	gauges := make([]metrics.IntegerGauge, len(s.scopes))
+	for i, child := range s.scopes {
+		gauges[i] = child.IntegerGauge(name)
+	}
+	return multiIntegerGauge(gauges)
+}
+
+func (s *scope) InfoGauge(name string) metrics.GaugeInfo {
+	gauges := make([]metrics.GaugeInfo, len(s.scopes))
+	for i, child := range s.scopes {
+		gauges[i] = child.InfoGauge(name)
+	}
+	return multiGaugeInfo(gauges)
+}
+
+func (s *scope) FuncGauge(name string, fn func() float64) {
+	for _, child := range s.scopes {
+		child.FuncGauge(name, fn)
+	}
+}
+
+func (s *scope) FuncIntGauge(name string, fn func() int64) {
+	for _, child := range s.scopes {
+		child.FuncIntGauge(name, fn)
+	}
+}
+
+func (s *scope) FuncCounter(name string, fn func() int64) {
+	for _, child := range s.scopes {
+		child.FuncCounter(name, fn)
+	}
+}
+
+func (s *scope) Timer(name string) metrics.Timer {
+	timers := make([]metrics.Timer, len(s.scopes))
+	for i, child := range s.scopes {
+		timers[i] = child.Timer(name)
+	}
+	return &multiTimer{timers: timers}
+}
+
+func (s *scope) Histogram(name string, buckets metrics.Buckets) metrics.Histogram {
+	histograms := make([]metrics.Histogram, len(s.scopes))
+	for i, child := range s.scopes {
+		histograms[i] = child.Histogram(name, buckets)
+	}
+	return &multiHistogram{histograms: histograms}
+}
+
+func (s *scope) Tagged(tags map[string]string) metrics.Scope {
+	tagged := make([]metrics.Scope, len(s.scopes))
+	for i, child := range s.scopes {
+		tagged[i] = child.Tagged(tags)
+	}
+	return &scope{scopes: tagged}
+}
+
+func (s *scope) SubScope(name string) metrics.Scope {
+	sub := make([]metrics.Scope, len(s.scopes))
+	for i, child := range s.scopes {
+		sub[i] = child.SubScope(name)
+	}
+	return &scope{scopes: sub}
+}
+
+func (s *scope) Capabilities() metrics.Capabilities {
+	var caps capabilities
+	caps.tagging = true
+	for _, child := range s.scopes {
+		c := child.Capabilities()
+		if c.Reporting() {
+			caps.reporting = true
+		}
+		if !c.Tagging() {
+			caps.tagging = false
+		}
+	}
+	return caps
+}
+
+type capabilities struct {
+	reporting bool
+	tagging   bool
+}
+
+func (c capabilities) Reporting() bool { return c.reporting }
+func (c capabilities) Tagging() bool   { return c.tagging }
+
+// multiCounter and multiGauge need no shared start time, so they forward
+// directly without an intermediate recorder type.
+type multiCounter []metrics.Counter
+
+func (m multiCounter) Inc(delta int64) {
+	for _, c := range m {
+		c.Inc(delta)
+	}
+}
+
+type multiGauge []metrics.Gauge
+
+func (m multiGauge) Update(value float64) {
+	for _, g := range m {
+		g.Update(value)
+	}
+}
+
+type multiGaugeInfo []metrics.GaugeInfo
+
+func (m multiGaugeInfo) Update(info map[string]string) {
+	for _, g := range m {
+		g.Update(info)
+	}
+}
+
+type multiIntegerGauge []metrics.IntegerGauge
+
+func (m multiIntegerGauge) Update(value int64) {
+	for _, g := range m {
+		g.Update(value)
+	}
+}
+
+func (m multiIntegerGauge) Inc(value int64) {
+	for _, g := range m {
+		g.Inc(value)
+	}
+}
+
+func (m multiIntegerGauge) Dec(value int64) {
+	for _, g := range m {
+		g.Dec(value)
+	}
+}
+
+// multiTimer fans a Timer out to N underlying timers. Start captures
+// time.Now() exactly once; Stop then replays the single elapsed
+// duration to every backing timer instead of starting N independent
+// stopwatches.
+type multiTimer struct {
+	timers []metrics.Timer
+}
+
+func (m *multiTimer) Record(value time.Duration) {
+	for _, t := range m.timers {
+		t.Record(value)
+	}
+}
+
+func (m *multiTimer) Start() metrics.Stopwatch {
+	return metrics.NewStopwatch(time.Now(), m)
+}
+
+func (m *multiTimer) RecordStopwatch(start time.Time) {
+	elapsed := time.Since(start)
+	for _, t := range m.timers {
+		t.Record(elapsed)
+	}
+}
+
+// multiHistogram fans a Histogram out to N underlying histograms, with
+// the same single-elapsed-duration behavior as multiTimer for Start/Stop.
+type multiHistogram struct {
+	histograms []metrics.Histogram
+}
+
+func (m *multiHistogram) RecordValue(value float64) {
+	for _, h := range m.histograms {
+		h.RecordValue(value)
+	}
+}
+
+func (m *multiHistogram) RecordDuration(value time.Duration) {
+	for _, h := range m.histograms {
+		h.RecordDuration(value)
+	}
+}
+
+func (m *multiHistogram) Start() metrics.Stopwatch {
+	return metrics.NewStopwatch(time.Now(), m)
+}
+
+func (m *multiHistogram) RecordStopwatch(start time.Time) {
+	elapsed := time.Since(start)
+	for _, h := range m.histograms {
+		h.RecordDuration(elapsed)
+	}
+}
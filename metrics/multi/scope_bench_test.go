@@ -0,0 +1,43 @@
+package multi
+
+import (
+	"testing"
+
+	"github.com/gottingen/kmetrics/metrics"
+)
+
+// BenchmarkSingleScope measures the baseline cost of reporting directly to
+// a single Scope, for comparison against BenchmarkMultiScopeOne below.
+func BenchmarkSingleScope(b *testing.B) {
+	one := metrics.NewTestScope("bench", nil)
+	counter := one.Counter("requests")
+	gauge := one.Gauge("queue_depth")
+	timer := one.Timer("latency")
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		counter.Inc(1)
+		gauge.Update(float64(i))
+		sw := timer.Start()
+		sw.Stop()
+	}
+}
+
+// BenchmarkMultiScopeOne measures the overhead NewMultiScope adds when
+// wrapping a single child scope. It should track BenchmarkSingleScope
+// closely, since every call forwards to exactly one underlying scope.
+func BenchmarkMultiScopeOne(b *testing.B) {
+	one := metrics.NewTestScope("bench", nil)
+	multi := NewMultiScope(one)
+	counter := multi.Counter("requests")
+	gauge := multi.Gauge("queue_depth")
+	timer := multi.Timer("latency")
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		counter.Inc(1)
+		gauge.Update(float64(i))
+		sw := timer.Start()
+		sw.Stop()
+	}
+}
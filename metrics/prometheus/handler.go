@@ -0,0 +1,121 @@
+package prometheus
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// contentType is the text exposition format content type expected by
+// Prometheus scrapers.
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// handler serves every metric collected in a registry as a Prometheus
+// text exposition format response.
+type handler struct {
+	registry *registry
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", contentType)
+	h.registry.mu.Lock()
+	defer h.registry.mu.Unlock()
+
+	for _, c := range sortedCounters(h.registry.counters) {
+		fmt.Fprintf(w, "%s %s\n", formatSeries(c.name, c.tags, nil), strconv.FormatInt(c.Load(), 10))
+	}
+	for _, g := range sortedGauges(h.registry.gauges) {
+		fmt.Fprintf(w, "%s %s\n", formatSeries(g.name, g.tags, nil), strconv.FormatFloat(g.Load(), 'g', -1, 64))
+	}
+	for _, g := range sortedIntGauges(h.registry.intGauges) {
+		fmt.Fprintf(w, "%s %s\n", formatSeries(g.name, g.tags, nil), strconv.FormatInt(g.Load(), 10))
+	}
+	for _, g := range sortedInfoGauges(h.registry.infoGauges) {
+		fmt.Fprintf(w, "%s 1\n", formatSeries(g.name, g.labels(), nil))
+	}
+	timeout := h.registry.opts.callbackTimeout()
+	for _, g := range sortedFuncGauges(h.registry.funcGauges) {
+		if v, ok := callFloat(g.fn, timeout); ok {
+			fmt.Fprintf(w, "%s %s\n", formatSeries(g.name, g.tags, nil), strconv.FormatFloat(v, 'g', -1, 64))
+		}
+	}
+	for _, g := range sortedFuncIntGauges(h.registry.funcIntGauges) {
+		if v, ok := callInt(g.fn, timeout); ok {
+			fmt.Fprintf(w, "%s %d\n", formatSeries(g.name, g.tags, nil), v)
+		}
+	}
+	for _, c := range sortedFuncCounters(h.registry.funcCounters) {
+		if v, ok := callInt(c.fn, timeout); ok {
+			fmt.Fprintf(w, "%s %d\n", formatSeries(c.name, c.tags, nil), v)
+		}
+	}
+	for _, hi := range sortedHistograms(h.registry.histograms) {
+		writeHistogram(w, hi)
+	}
+	for _, t := range sortedTimers(h.registry.timers) {
+		if t.isSummary {
+			writeSummary(w, t)
+		} else {
+			writeHistogram(w, t.histogram)
+		}
+	}
+}
+
+func writeHistogram(w io.Writer, hi *histogram) {
+	// hi.buckets[i] is already a cumulative count (record() increments
+	// every bucket whose bound is >= the observed value), so bucket
+	// lines are emitted as-is rather than re-accumulated.
+	for i, bound := range hi.bounds {
+		count := atomic.LoadInt64(&hi.buckets[i])
+		le := strconv.FormatFloat(bound, 'g', -1, 64)
+		fmt.Fprintf(w, "%s %d\n", formatSeries(hi.name+"_bucket", hi.tags, []label{{"le", le}}), count)
+	}
+	count := atomic.LoadInt64(&hi.count)
+	fmt.Fprintf(w, "%s %d\n", formatSeries(hi.name+"_bucket", hi.tags, []label{{"le", "+Inf"}}), count)
+	sum := math.Float64frombits(atomic.LoadUint64(&hi.sum))
+	fmt.Fprintf(w, "%s %s\n", formatSeries(hi.name+"_sum", hi.tags, nil), strconv.FormatFloat(sum, 'g', -1, 64))
+	fmt.Fprintf(w, "%s %d\n", formatSeries(hi.name+"_count", hi.tags, nil), count)
+}
+
+func writeSummary(w io.Writer, t *timerMetric) {
+	qs, values, sum, count := t.quantiles()
+	for i, q := range qs {
+		quantile := strconv.FormatFloat(q, 'g', -1, 64)
+		fmt.Fprintf(w, "%s %s\n", formatSeries(t.name, t.tags, []label{{"quantile", quantile}}), strconv.FormatFloat(values[i], 'g', -1, 64))
+	}
+	fmt.Fprintf(w, "%s %s\n", formatSeries(t.name+"_sum", t.tags, nil), strconv.FormatFloat(sum, 'g', -1, 64))
+	fmt.Fprintf(w, "%s %d\n", formatSeries(t.name+"_count", t.tags, nil), count)
+}
+
+type label struct {
+	name  string
+	value string
+}
+
+// formatSeries renders "name{k=\"v\",...}" combining the scope's own tags
+// with any extra labels (e.g. "le", "quantile") the caller supplies.
+func formatSeries(name string, tags map[string]string, extra []label) string {
+	labels := make([]label, 0, len(tags)+len(extra))
+	for k, v := range tags {
+		labels = append(labels, label{sanitizeLabel(k), v})
+	}
+	labels = append(labels, extra...)
+	sort.Slice(labels, func(i, j int) bool { return labels[i].name < labels[j].name })
+
+	if len(labels) == 0 {
+		return name
+	}
+	parts := make([]string, len(labels))
+	for i, l := range labels {
+		// %q already escapes backslash, double-quote and newline the way
+		// Prometheus text exposition format expects; escaping again here
+		// would double the backslashes.
+		parts[i] = fmt.Sprintf("%s=%q", l.name, l.value)
+	}
+	return name + "{" + strings.Join(parts, ",") + "}"
+}
@@ -0,0 +1,297 @@
+package prometheus
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gottingen/kmetrics/metrics"
+)
+
+type counter struct {
+	name  string
+	tags  map[string]string
+	value int64
+}
+
+func (c *counter) Inc(delta int64) {
+	atomic.AddInt64(&c.value, delta)
+}
+
+func (c *counter) Load() int64 {
+	return atomic.LoadInt64(&c.value)
+}
+
+type gauge struct {
+	name  string
+	tags  map[string]string
+	value uint64 // math.Float64bits of the current value
+}
+
+func (g *gauge) Update(value float64) {
+	atomic.StoreUint64(&g.value, math.Float64bits(value))
+}
+
+func (g *gauge) Load() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&g.value))
+}
+
+type intGauge struct {
+	name  string
+	tags  map[string]string
+	value int64
+}
+
+func (g *intGauge) Update(value int64) {
+	atomic.StoreInt64(&g.value, value)
+}
+
+func (g *intGauge) Inc(value int64) {
+	atomic.AddInt64(&g.value, value)
+}
+
+func (g *intGauge) Dec(value int64) {
+	atomic.AddInt64(&g.value, -value)
+}
+
+func (g *intGauge) Load() int64 {
+	return atomic.LoadInt64(&g.value)
+}
+
+// infoGauge backs metrics.GaugeInfo: a gauge whose value is always 1,
+// with its payload flattened into labels rather than a numeric value.
+type infoGauge struct {
+	name string
+	tags map[string]string
+
+	mu      sync.Mutex
+	payload map[string]string
+}
+
+func (g *infoGauge) Update(info map[string]string) {
+	payload := make(map[string]string, len(info))
+	for k, v := range info {
+		payload[k] = v
+	}
+	g.mu.Lock()
+	g.payload = payload
+	g.mu.Unlock()
+}
+
+func (g *infoGauge) labels() map[string]string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	labels := make(map[string]string, len(g.tags)+len(g.payload))
+	for k, v := range g.tags {
+		labels[k] = v
+	}
+	for k, v := range g.payload {
+		labels[k] = v
+	}
+	return labels
+}
+
+// funcGauge, funcIntGauge and funcCounter back Scope.FuncGauge,
+// FuncIntGauge and FuncCounter: their value is computed by invoking fn
+// at scrape time rather than by calling Update/Inc.
+type funcGauge struct {
+	name string
+	tags map[string]string
+	fn   func() float64
+}
+
+type funcIntGauge struct {
+	name string
+	tags map[string]string
+	fn   func() int64
+}
+
+type funcCounter struct {
+	name string
+	tags map[string]string
+	fn   func() int64
+}
+
+// callFloat invokes fn with panic recovery and a timeout, so that a
+// misbehaving callback can't crash or hang a scrape. ok is false if fn
+// panicked or didn't return within timeout.
+func callFloat(fn func() float64, timeout time.Duration) (value float64, ok bool) {
+	type result struct {
+		value float64
+		ok    bool
+	}
+	ch := make(chan result, 1)
+	go func() {
+		defer func() {
+			if recover() != nil {
+				ch <- result{}
+			}
+		}()
+		ch <- result{value: fn(), ok: true}
+	}()
+	select {
+	case r := <-ch:
+		return r.value, r.ok
+	case <-time.After(timeout):
+		return 0, false
+	}
+}
+
+// callInt is callFloat for callbacks returning an integer.
+func callInt(fn func() int64, timeout time.Duration) (value int64, ok bool) {
+	type result struct {
+		value int64
+		ok    bool
+	}
+	ch := make(chan result, 1)
+	go func() {
+		defer func() {
+			if recover() != nil {
+				ch <- result{}
+			}
+		}()
+		ch <- result{value: fn(), ok: true}
+	}()
+	select {
+	case r := <-ch:
+		return r.value, r.ok
+	case <-time.After(timeout):
+		return 0, false
+	}
+}
+
+// histogram is a Prometheus-style cumulative histogram: buckets carry the
+// count of observations less than or equal to their upper bound.
+type histogram struct {
+	name    string
+	tags    map[string]string
+	bounds  []float64
+	buckets []int64 // one per bound; the implicit +Inf bucket is count
+	sum     uint64  // math.Float64bits of the running sum
+	count   int64
+}
+
+func newHistogram(name string, tags map[string]string, bounds []float64) *histogram {
+	sorted := append([]float64(nil), bounds...)
+	sort.Float64s(sorted)
+	return &histogram{
+		name:    name,
+		tags:    tags,
+		bounds:  sorted,
+		buckets: make([]int64, len(sorted)),
+	}
+}
+
+func (h *histogram) RecordValue(value float64) {
+	h.record(value)
+}
+
+func (h *histogram) RecordDuration(value time.Duration) {
+	h.record(value.Seconds())
+}
+
+func (h *histogram) record(value float64) {
+	for i, bound := range h.bounds {
+		if value <= bound {
+			atomic.AddInt64(&h.buckets[i], 1)
+		}
+	}
+	atomic.AddInt64(&h.count, 1)
+	for {
+		old := atomic.LoadUint64(&h.sum)
+		next := math.Float64bits(math.Float64frombits(old) + value)
+		if atomic.CompareAndSwapUint64(&h.sum, old, next) {
+			return
+		}
+	}
+}
+
+func (h *histogram) Start() metrics.Stopwatch {
+	return metrics.NewStopwatch(time.Now(), h)
+}
+
+func (h *histogram) RecordStopwatch(start time.Time) {
+	h.RecordDuration(time.Since(start))
+}
+
+// timerMetric backs metrics.Timer, rendered either as a histogram or as a
+// summary depending on the owning registry's Options.
+type timerMetric struct {
+	histogram *histogram
+
+	summaryMu         sync.Mutex
+	summarySamples    []float64
+	summaryObjectives map[float64]float64
+	isSummary         bool
+	name              string
+	tags              map[string]string
+}
+
+// maxSummarySamples bounds the reservoir kept for summary quantile
+// estimation so a hot timer can't grow it unbounded between scrapes.
+const maxSummarySamples = 4096
+
+func newTimerMetric(name string, tags map[string]string, typ TimerType, objectives map[float64]float64) *timerMetric {
+	t := &timerMetric{name: name, tags: tags}
+	if typ == TimerTypeSummary {
+		t.isSummary = true
+		t.summaryObjectives = objectives
+		return t
+	}
+	t.histogram = newHistogram(name, tags, defaultValueBuckets)
+	return t
+}
+
+func (t *timerMetric) Record(value time.Duration) {
+	if !t.isSummary {
+		t.histogram.RecordDuration(value)
+		return
+	}
+	t.summaryMu.Lock()
+	if len(t.summarySamples) >= maxSummarySamples {
+		t.summarySamples = t.summarySamples[1:]
+	}
+	t.summarySamples = append(t.summarySamples, value.Seconds())
+	t.summaryMu.Unlock()
+}
+
+func (t *timerMetric) Start() metrics.Stopwatch {
+	return metrics.NewStopwatch(time.Now(), t)
+}
+
+func (t *timerMetric) RecordStopwatch(start time.Time) {
+	t.Record(time.Since(start))
+}
+
+// quantiles returns the configured quantiles and their estimated values
+// over the samples currently held in the reservoir.
+func (t *timerMetric) quantiles() (qs []float64, values []float64, sum float64, count int64) {
+	t.summaryMu.Lock()
+	samples := append([]float64(nil), t.summarySamples...)
+	t.summaryMu.Unlock()
+
+	sort.Float64s(samples)
+	for _, s := range samples {
+		sum += s
+	}
+	count = int64(len(samples))
+
+	for q := range t.summaryObjectives {
+		qs = append(qs, q)
+	}
+	sort.Float64s(qs)
+	for _, q := range qs {
+		values = append(values, percentile(samples, q))
+	}
+	return qs, values, sum, count
+}
+
+func percentile(sorted []float64, q float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	idx := int(q * float64(len(sorted)-1))
+	return sorted[idx]
+}
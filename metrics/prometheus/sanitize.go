@@ -0,0 +1,41 @@
+package prometheus
+
+import "strings"
+
+// sanitizeName rewrites s so that it is a valid Prometheus metric name:
+// [a-zA-Z_:][a-zA-Z0-9_:]*. Any other character, including the scope
+// separator, is replaced with an underscore.
+func sanitizeName(s string) string {
+	return sanitize(s, true)
+}
+
+// sanitizeLabel rewrites s so that it is a valid Prometheus label name:
+// [a-zA-Z_][a-zA-Z0-9_]*.
+func sanitizeLabel(s string) string {
+	return sanitize(s, false)
+}
+
+func sanitize(s string, allowColon bool) string {
+	if s == "" {
+		return s
+	}
+	var b strings.Builder
+	b.Grow(len(s))
+	for i, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_':
+			b.WriteRune(r)
+		case r == ':' && allowColon:
+			b.WriteRune(r)
+		case r >= '0' && r <= '9':
+			if i == 0 {
+				b.WriteByte('_')
+			} else {
+				b.WriteRune(r)
+			}
+		default:
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
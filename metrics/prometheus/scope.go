@@ -0,0 +1,302 @@
+// Package prometheus implements the metrics.Scope surface on top of an
+// in-process registry that can be scraped over HTTP in Prometheus text
+// exposition format.
+package prometheus
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gottingen/kmetrics/metrics"
+)
+
+// separator joins scope name prefixes together, matching the convention
+// used by the rest of the metrics package.
+const separator = "."
+
+// TimerType selects how Scope.Timer values are rendered by the Handler.
+type TimerType int
+
+const (
+	// TimerTypeHistogram renders timers as a Prometheus histogram. This is
+	// the default, since it aggregates correctly across scrape intervals
+	// and instances.
+	TimerTypeHistogram TimerType = iota
+	// TimerTypeSummary renders timers as a Prometheus summary computing
+	// the quantiles configured via Options.SummaryObjectives.
+	TimerTypeSummary
+)
+
+// Options configures the behavior of a prometheus Scope.
+type Options struct {
+	// DefaultTimerType is the TimerType used for timers that don't have a
+	// per-metric override in TimerTypes. Defaults to TimerTypeHistogram.
+	DefaultTimerType TimerType
+
+	// TimerTypes overrides DefaultTimerType for specific timer metric
+	// names (the fully qualified, pre-sanitization name).
+	TimerTypes map[string]TimerType
+
+	// SummaryObjectives is the set of quantile -> allowed error rank used
+	// for timers rendered as summaries. Defaults to the standard
+	// 0.5/0.9/0.99 objectives if left nil.
+	SummaryObjectives map[float64]float64
+
+	// DefaultValueBuckets are the bucket upper bounds used for
+	// Histogram() calls made with nil buckets. Defaults to a standard
+	// Prometheus-style exponential bucket set.
+	DefaultValueBuckets []float64
+
+	// CallbackTimeout bounds how long a FuncGauge/FuncIntGauge/
+	// FuncCounter callback is allowed to run when invoked during a
+	// scrape. Defaults to 1 second.
+	CallbackTimeout time.Duration
+}
+
+var defaultValueBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+var defaultSummaryObjectives = map[float64]float64{0.5: 0.05, 0.9: 0.01, 0.99: 0.001}
+
+func (o Options) timerType(name string) TimerType {
+	if t, ok := o.TimerTypes[name]; ok {
+		return t
+	}
+	return o.DefaultTimerType
+}
+
+func (o Options) summaryObjectives() map[float64]float64 {
+	if len(o.SummaryObjectives) > 0 {
+		return o.SummaryObjectives
+	}
+	return defaultSummaryObjectives
+}
+
+func (o Options) defaultValueBuckets() []float64 {
+	if len(o.DefaultValueBuckets) > 0 {
+		return o.DefaultValueBuckets
+	}
+	return defaultValueBuckets
+}
+
+const defaultCallbackTimeout = time.Second
+
+func (o Options) callbackTimeout() time.Duration {
+	if o.CallbackTimeout > 0 {
+		return o.CallbackTimeout
+	}
+	return defaultCallbackTimeout
+}
+
+// registry is the shared, mutable set of metrics collected across a root
+// Scope and every Scope derived from it via Tagged/SubScope.
+type registry struct {
+	mu         sync.Mutex
+	opts       Options
+	counters   map[string]*counter
+	gauges     map[string]*gauge
+	intGauges  map[string]*intGauge
+	histograms map[string]*histogram
+	timers     map[string]*timerMetric
+	infoGauges map[string]*infoGauge
+
+	funcGauges    map[string]*funcGauge
+	funcIntGauges map[string]*funcIntGauge
+	funcCounters  map[string]*funcCounter
+}
+
+func newRegistry(opts Options) *registry {
+	return &registry{
+		opts:          opts,
+		counters:      make(map[string]*counter),
+		gauges:        make(map[string]*gauge),
+		intGauges:     make(map[string]*intGauge),
+		histograms:    make(map[string]*histogram),
+		timers:        make(map[string]*timerMetric),
+		infoGauges:    make(map[string]*infoGauge),
+		funcGauges:    make(map[string]*funcGauge),
+		funcIntGauges: make(map[string]*funcIntGauge),
+		funcCounters:  make(map[string]*funcCounter),
+	}
+}
+
+// scope implements metrics.Scope, reporting every emitted metric into a
+// shared registry keyed by its fully-qualified name and tag set.
+type scope struct {
+	registry *registry
+	prefix   string
+	tags     map[string]string
+}
+
+// NewRootScope creates a new root prometheus Scope together with the
+// http.Handler that serves everything reported through it, or through
+// any Scope derived from it via Tagged/SubScope, in Prometheus text
+// exposition format.
+func NewRootScope(prefix string, tags map[string]string, opts Options) (metrics.Scope, http.Handler) {
+	r := newRegistry(opts)
+	s := &scope{registry: r, prefix: prefix, tags: copyTags(tags)}
+	return s, &handler{registry: r}
+}
+
+func copyTags(tags map[string]string) map[string]string {
+	out := make(map[string]string, len(tags))
+	for k, v := range tags {
+		out[k] = v
+	}
+	return out
+}
+
+func (s *scope) fullName(name string) string {
+	if s.prefix == "" {
+		return name
+	}
+	return s.prefix + separator + name
+}
+
+// key identifies a metric series by its sanitized name plus its sorted
+// tag set, so that Tagged scopes with different tag values don't collide.
+func (s *scope) key(name string) string {
+	fq := sanitizeName(s.fullName(name))
+	if len(s.tags) == 0 {
+		return fq
+	}
+	keys := make([]string, 0, len(s.tags))
+	for k := range s.tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	b.WriteString(fq)
+	for _, k := range keys {
+		b.WriteByte('\xff')
+		b.WriteString(sanitizeLabel(k))
+		b.WriteByte('=')
+		b.WriteString(s.tags[k])
+	}
+	return b.String()
+}
+
+func (s *scope) Counter(name string) metrics.Counter {
+	k := s.key(name)
+	s.registry.mu.Lock()
+	defer s.registry.mu.Unlock()
+	if c, ok := s.registry.counters[k]; ok {
+		return c
+	}
+	c := &counter{name: sanitizeName(s.fullName(name)), tags: s.tags}
+	s.registry.counters[k] = c
+	return c
+}
+
+func (s *scope) Gauge(name string) metrics.Gauge {
+	k := s.key(name)
+	s.registry.mu.Lock()
+	defer s.registry.mu.Unlock()
+	if g, ok := s.registry.gauges[k]; ok {
+		return g
+	}
+	g := &gauge{name: sanitizeName(s.fullName(name)), tags: s.tags}
+	s.registry.gauges[k] = g
+	return g
+}
+
+func (s *scope) IntegerGauge(name string) metrics.IntegerGauge {
+	k := s.key(name)
+	s.registry.mu.Lock()
+	defer s.registry.mu.Unlock()
+	if g, ok := s.registry.intGauges[k]; ok {
+		return g
+	}
+	g := &intGauge{name: sanitizeName(s.fullName(name)), tags: s.tags}
+	s.registry.intGauges[k] = g
+	return g
+}
+
+// InfoGauge reports an informational metric name{<payload>} 1, with the
+// payload map flattened into labels alongside the scope's own tags.
+func (s *scope) InfoGauge(name string) metrics.GaugeInfo {
+	k := s.key(name)
+	s.registry.mu.Lock()
+	defer s.registry.mu.Unlock()
+	if g, ok := s.registry.infoGauges[k]; ok {
+		return g
+	}
+	g := &infoGauge{name: sanitizeName(s.fullName(name)), tags: s.tags}
+	s.registry.infoGauges[k] = g
+	return g
+}
+
+func (s *scope) FuncGauge(name string, fn func() float64) {
+	k := s.key(name)
+	s.registry.mu.Lock()
+	defer s.registry.mu.Unlock()
+	s.registry.funcGauges[k] = &funcGauge{name: sanitizeName(s.fullName(name)), tags: s.tags, fn: fn}
+}
+
+func (s *scope) FuncIntGauge(name string, fn func() int64) {
+	k := s.key(name)
+	s.registry.mu.Lock()
+	defer s.registry.mu.Unlock()
+	s.registry.funcIntGauges[k] = &funcIntGauge{name: sanitizeName(s.fullName(name)), tags: s.tags, fn: fn}
+}
+
+func (s *scope) FuncCounter(name string, fn func() int64) {
+	k := s.key(name)
+	s.registry.mu.Lock()
+	defer s.registry.mu.Unlock()
+	s.registry.funcCounters[k] = &funcCounter{name: sanitizeName(s.fullName(name)), tags: s.tags, fn: fn}
+}
+
+func (s *scope) Timer(name string) metrics.Timer {
+	k := s.key(name)
+	s.registry.mu.Lock()
+	defer s.registry.mu.Unlock()
+	if t, ok := s.registry.timers[k]; ok {
+		return t
+	}
+	fq := sanitizeName(s.fullName(name))
+	t := newTimerMetric(fq, s.tags, s.registry.opts.timerType(s.fullName(name)), s.registry.opts.summaryObjectives())
+	s.registry.timers[k] = t
+	return t
+}
+
+func (s *scope) Histogram(name string, buckets metrics.Buckets) metrics.Histogram {
+	k := s.key(name)
+	s.registry.mu.Lock()
+	defer s.registry.mu.Unlock()
+	if h, ok := s.registry.histograms[k]; ok {
+		return h
+	}
+	var bounds []float64
+	if buckets == nil {
+		bounds = s.registry.opts.defaultValueBuckets()
+	} else {
+		bounds = buckets.AsValues()
+	}
+	h := newHistogram(sanitizeName(s.fullName(name)), s.tags, bounds)
+	s.registry.histograms[k] = h
+	return h
+}
+
+func (s *scope) Tagged(tags map[string]string) metrics.Scope {
+	merged := copyTags(s.tags)
+	for k, v := range tags {
+		merged[k] = v
+	}
+	return &scope{registry: s.registry, prefix: s.prefix, tags: merged}
+}
+
+func (s *scope) SubScope(name string) metrics.Scope {
+	return &scope{registry: s.registry, prefix: s.fullName(name), tags: copyTags(s.tags)}
+}
+
+func (s *scope) Capabilities() metrics.Capabilities {
+	return capabilities{}
+}
+
+type capabilities struct{}
+
+func (capabilities) Reporting() bool { return true }
+func (capabilities) Tagging() bool   { return true }
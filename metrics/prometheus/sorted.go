@@ -0,0 +1,88 @@
+package prometheus
+
+import "sort"
+
+// Every collection is rendered sorted by metric name rather than in Go's
+// randomized map iteration order. Series that share a name and differ
+// only in their tags are not ordered among themselves.
+
+func sortedCounters(m map[string]*counter) []*counter {
+	out := make([]*counter, 0, len(m))
+	for _, v := range m {
+		out = append(out, v)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].name < out[j].name })
+	return out
+}
+
+func sortedGauges(m map[string]*gauge) []*gauge {
+	out := make([]*gauge, 0, len(m))
+	for _, v := range m {
+		out = append(out, v)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].name < out[j].name })
+	return out
+}
+
+func sortedIntGauges(m map[string]*intGauge) []*intGauge {
+	out := make([]*intGauge, 0, len(m))
+	for _, v := range m {
+		out = append(out, v)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].name < out[j].name })
+	return out
+}
+
+func sortedInfoGauges(m map[string]*infoGauge) []*infoGauge {
+	out := make([]*infoGauge, 0, len(m))
+	for _, v := range m {
+		out = append(out, v)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].name < out[j].name })
+	return out
+}
+
+func sortedFuncGauges(m map[string]*funcGauge) []*funcGauge {
+	out := make([]*funcGauge, 0, len(m))
+	for _, v := range m {
+		out = append(out, v)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].name < out[j].name })
+	return out
+}
+
+func sortedFuncIntGauges(m map[string]*funcIntGauge) []*funcIntGauge {
+	out := make([]*funcIntGauge, 0, len(m))
+	for _, v := range m {
+		out = append(out, v)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].name < out[j].name })
+	return out
+}
+
+func sortedFuncCounters(m map[string]*funcCounter) []*funcCounter {
+	out := make([]*funcCounter, 0, len(m))
+	for _, v := range m {
+		out = append(out, v)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].name < out[j].name })
+	return out
+}
+
+func sortedHistograms(m map[string]*histogram) []*histogram {
+	out := make([]*histogram, 0, len(m))
+	for _, v := range m {
+		out = append(out, v)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].name < out[j].name })
+	return out
+}
+
+func sortedTimers(m map[string]*timerMetric) []*timerMetric {
+	out := make([]*timerMetric, 0, len(m))
+	for _, v := range m {
+		out = append(out, v)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].name < out[j].name })
+	return out
+}
@@ -0,0 +1,292 @@
+package metrics
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Struct tags recognized by Report.
+const (
+	reportTagMetric  = "metric"
+	reportTagType    = "type"
+	reportTagTags    = "tags"
+	reportTagBuckets = "buckets"
+)
+
+// Recognized values of the "type" struct tag.
+const (
+	reportTypeCounter   = "counter"
+	reportTypeGauge     = "gauge"
+	reportTypeHistogram = "histogram"
+	reportTypeTimer     = "timer"
+)
+
+// Report walks v, a pointer to a struct (or a struct), reading current
+// field values and emitting them to scope according to `metric:"..."` and
+// `type:"counter|gauge|histogram|timer"` struct tags. Fields may also
+// carry a `tags:"k=v,k2=v2"` tag applying additional Scope.Tagged tags,
+// and, for histogram fields, a `buckets:"..."` tag giving bucket bounds
+// as a comma-separated list of float64s.
+//
+// Nested structs are walked recursively, concatenating their `metric:`
+// prefix with their parent's using the scope separator ".". A
+// time.Duration field tagged as a histogram is recorded with
+// RecordDuration rather than RecordValue.
+//
+// The Counter/Gauge/Histogram/Timer instances resolved for a given
+// (scope, struct type) pair are cached, so calling Report repeatedly for
+// the same scope and type performs no further allocation beyond reading
+// the struct's current field values.
+//
+// A counter-tagged field is read as a per-call delta: its current value
+// is passed to Counter.Inc on every call to Report, so the field should
+// hold however much the counter increased since the last call, not a
+// monotonic running total. Reporting an absolute total through a
+// counter field will over-count it on every subsequent call.
+func Report(scope Scope, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return fmt.Errorf("metrics: Report given a nil %s", rv.Type())
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return fmt.Errorf("metrics: Report requires a struct or pointer to struct, got %s", rv.Type())
+	}
+
+	bindings, err := bindingsFor(scope, rv.Type())
+	if err != nil {
+		return err
+	}
+	for _, b := range bindings {
+		b.report(rv)
+	}
+	return nil
+}
+
+// reportBinding is a single field of a reported struct, pre-resolved
+// against a Scope so repeated Report calls need only read the field.
+type reportBinding struct {
+	index   []int
+	kind    string
+	counter Counter
+	gauge   Gauge
+	hist    Histogram
+	timer   Timer
+}
+
+func (b *reportBinding) report(rv reflect.Value) {
+	fv := rv.FieldByIndex(b.index)
+	switch b.kind {
+	case reportTypeCounter:
+		b.counter.Inc(intValue(fv))
+	case reportTypeGauge:
+		b.gauge.Update(floatValue(fv))
+	case reportTypeHistogram:
+		if d, ok := durationValue(fv); ok {
+			b.hist.RecordDuration(d)
+		} else {
+			b.hist.RecordValue(floatValue(fv))
+		}
+	case reportTypeTimer:
+		if d, ok := durationValue(fv); ok {
+			b.timer.Record(d)
+		}
+	}
+}
+
+func intValue(v reflect.Value) int64 {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		return int64(v.Float())
+	default:
+		return 0
+	}
+}
+
+func floatValue(v reflect.Value) float64 {
+	switch v.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return v.Float()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint())
+	default:
+		return 0
+	}
+}
+
+func durationValue(v reflect.Value) (time.Duration, bool) {
+	if v.Type() == reflect.TypeOf(time.Duration(0)) {
+		return time.Duration(v.Int()), true
+	}
+	return 0, false
+}
+
+// reportCacheKey identifies a (scope, struct type) pair whose bindings
+// have already been resolved.
+type reportCacheKey struct {
+	scope Scope
+	typ   reflect.Type
+}
+
+var (
+	reportCacheMu sync.RWMutex
+	reportCache   = map[reportCacheKey][]*reportBinding{}
+)
+
+func bindingsFor(scope Scope, typ reflect.Type) ([]*reportBinding, error) {
+	key := reportCacheKey{scope: scope, typ: typ}
+
+	reportCacheMu.RLock()
+	bindings, ok := reportCache[key]
+	reportCacheMu.RUnlock()
+	if ok {
+		return bindings, nil
+	}
+
+	reportCacheMu.Lock()
+	defer reportCacheMu.Unlock()
+	if bindings, ok := reportCache[key]; ok {
+		return bindings, nil
+	}
+
+	bindings, err := buildBindings(scope, typ, nil, "")
+	if err != nil {
+		return nil, err
+	}
+	reportCache[key] = bindings
+	return bindings, nil
+}
+
+func buildBindings(scope Scope, typ reflect.Type, index []int, prefix string) ([]*reportBinding, error) {
+	var bindings []*reportBinding
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.PkgPath != "" {
+			continue // unexported
+		}
+		fieldIndex := append(append([]int(nil), index...), i)
+
+		fieldScope := scope
+		if tagsTag, ok := field.Tag.Lookup(reportTagTags); ok {
+			fieldScope = fieldScope.Tagged(parseTags(tagsTag))
+		}
+
+		metricName := field.Tag.Get(reportTagMetric)
+		if metricName == "" {
+			if field.Type.Kind() == reflect.Struct {
+				nested, err := buildBindings(fieldScope, field.Type, fieldIndex, prefix)
+				if err != nil {
+					return nil, err
+				}
+				bindings = append(bindings, nested...)
+			}
+			continue
+		}
+		if prefix != "" {
+			metricName = prefix + "." + metricName
+		}
+
+		if field.Type.Kind() == reflect.Struct {
+			nested, err := buildBindings(fieldScope, field.Type, fieldIndex, metricName)
+			if err != nil {
+				return nil, err
+			}
+			bindings = append(bindings, nested...)
+			continue
+		}
+
+		typeTag := field.Tag.Get(reportTagType)
+		b := &reportBinding{index: fieldIndex, kind: typeTag}
+		switch typeTag {
+		case reportTypeCounter:
+			b.counter = fieldScope.Counter(metricName)
+		case reportTypeGauge:
+			b.gauge = fieldScope.Gauge(metricName)
+		case reportTypeHistogram:
+			b.hist = fieldScope.Histogram(metricName, parseBuckets(field.Tag.Get(reportTagBuckets)))
+		case reportTypeTimer:
+			b.timer = fieldScope.Timer(metricName)
+		default:
+			return nil, fmt.Errorf("metrics: field %s has metric tag %q but an unrecognized or missing type tag %q", field.Name, metricName, typeTag)
+		}
+		bindings = append(bindings, b)
+	}
+	return bindings, nil
+}
+
+// parseTags parses a `tags:"k=v,k2=v2"` struct tag value.
+func parseTags(s string) map[string]string {
+	tags := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		tags[kv[0]] = kv[1]
+	}
+	return tags
+}
+
+// parseBuckets parses a `buckets:"0.1,0.5,1"` struct tag value into a
+// Buckets of value bounds. An empty tag yields nil, which tells the
+// Scope to use its own default buckets.
+func parseBuckets(s string) Buckets {
+	if s == "" {
+		return nil
+	}
+	var values reportBuckets
+	for _, raw := range strings.Split(s, ",") {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			continue
+		}
+		values = append(values, f)
+	}
+	return values
+}
+
+// reportBuckets is a minimal Buckets implementation for bounds parsed
+// from a `buckets:` struct tag.
+type reportBuckets []float64
+
+func (b reportBuckets) String() string {
+	strs := make([]string, len(b))
+	for i, v := range b {
+		strs[i] = strconv.FormatFloat(v, 'g', -1, 64)
+	}
+	return "[" + strings.Join(strs, " ") + "]"
+}
+
+func (b reportBuckets) Len() int           { return len(b) }
+func (b reportBuckets) Less(i, j int) bool { return b[i] < b[j] }
+func (b reportBuckets) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+
+func (b reportBuckets) AsValues() []float64 { return b }
+
+func (b reportBuckets) AsDurations() []time.Duration {
+	durations := make([]time.Duration, len(b))
+	for i, v := range b {
+		durations[i] = time.Duration(v * float64(time.Second))
+	}
+	return durations
+}
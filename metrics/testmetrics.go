@@ -0,0 +1,222 @@
+package metrics
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type testCounter struct {
+	name  string
+	tags  map[string]string
+	value int64
+}
+
+func (c *testCounter) Inc(delta int64) {
+	atomic.AddInt64(&c.value, delta)
+}
+
+func (c *testCounter) Load() int64 {
+	return atomic.LoadInt64(&c.value)
+}
+
+type testGauge struct {
+	name  string
+	tags  map[string]string
+	mu    sync.Mutex
+	value float64
+}
+
+func (g *testGauge) Update(value float64) {
+	g.mu.Lock()
+	g.value = value
+	g.mu.Unlock()
+}
+
+func (g *testGauge) Load() float64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.value
+}
+
+type testIntGauge struct {
+	name  string
+	tags  map[string]string
+	value int64
+}
+
+func (g *testIntGauge) Update(value int64) {
+	atomic.StoreInt64(&g.value, value)
+}
+
+func (g *testIntGauge) Inc(value int64) {
+	atomic.AddInt64(&g.value, value)
+}
+
+func (g *testIntGauge) Dec(value int64) {
+	atomic.AddInt64(&g.value, -value)
+}
+
+func (g *testIntGauge) Load() int64 {
+	return atomic.LoadInt64(&g.value)
+}
+
+type testInfoGauge struct {
+	name string
+	tags map[string]string
+	mu   sync.Mutex
+	info map[string]string
+}
+
+func (g *testInfoGauge) Update(info map[string]string) {
+	payload := make(map[string]string, len(info))
+	for k, v := range info {
+		payload[k] = v
+	}
+	g.mu.Lock()
+	g.info = payload
+	g.mu.Unlock()
+}
+
+// Load returns a copy of the gauge's current info payload.
+func (g *testInfoGauge) Load() map[string]string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	out := make(map[string]string, len(g.info))
+	for k, v := range g.info {
+		out[k] = v
+	}
+	return out
+}
+
+type testFuncGauge struct {
+	name string
+	tags map[string]string
+	fn   func() float64
+}
+
+type testFuncIntGauge struct {
+	name string
+	tags map[string]string
+	fn   func() int64
+}
+
+type testFuncCounter struct {
+	name string
+	tags map[string]string
+	fn   func() int64
+}
+
+type testTimer struct {
+	name string
+	tags map[string]string
+
+	mu     sync.Mutex
+	values []time.Duration
+}
+
+func (t *testTimer) Record(value time.Duration) {
+	t.mu.Lock()
+	t.values = append(t.values, value)
+	t.mu.Unlock()
+}
+
+func (t *testTimer) Start() Stopwatch {
+	return NewStopwatch(time.Now(), t)
+}
+
+func (t *testTimer) RecordStopwatch(start time.Time) {
+	t.Record(time.Since(start))
+}
+
+// Load returns a copy of every duration recorded so far.
+func (t *testTimer) Load() []time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make([]time.Duration, len(t.values))
+	copy(out, t.values)
+	return out
+}
+
+// testHistogram buckets observations by the upper bound of the
+// configured Buckets, matching the (non-cumulative) per-bucket counts a
+// Snapshot exposes for assertions.
+type testHistogram struct {
+	name    string
+	tags    map[string]string
+	buckets Buckets
+
+	mu        sync.Mutex
+	values    map[float64]int64
+	durations map[time.Duration]int64
+}
+
+func (h *testHistogram) RecordValue(value float64) {
+	bound := h.valueBucket(value)
+	h.mu.Lock()
+	h.values[bound]++
+	h.mu.Unlock()
+}
+
+func (h *testHistogram) RecordDuration(value time.Duration) {
+	bound := h.durationBucket(value)
+	h.mu.Lock()
+	h.durations[bound]++
+	h.mu.Unlock()
+}
+
+func (h *testHistogram) Start() Stopwatch {
+	return NewStopwatch(time.Now(), h)
+}
+
+func (h *testHistogram) RecordStopwatch(start time.Time) {
+	h.RecordDuration(time.Since(start))
+}
+
+// valueBucket returns the smallest configured bucket upper bound that is
+// >= value, or value itself if there are no configured buckets or value
+// exceeds every bound.
+func (h *testHistogram) valueBucket(value float64) float64 {
+	if h.buckets == nil {
+		return value
+	}
+	bounds := append([]float64(nil), h.buckets.AsValues()...)
+	sort.Float64s(bounds)
+	for _, b := range bounds {
+		if value <= b {
+			return b
+		}
+	}
+	return value
+}
+
+func (h *testHistogram) durationBucket(value time.Duration) time.Duration {
+	if h.buckets == nil {
+		return value
+	}
+	bounds := append([]time.Duration(nil), h.buckets.AsDurations()...)
+	sort.Slice(bounds, func(i, j int) bool { return bounds[i] < bounds[j] })
+	for _, b := range bounds {
+		if value <= b {
+			return b
+		}
+	}
+	return value
+}
+
+// Load returns copies of the current per-bucket value and duration
+// counts.
+func (h *testHistogram) Load() (values map[float64]int64, durations map[time.Duration]int64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	values = make(map[float64]int64, len(h.values))
+	for k, v := range h.values {
+		values[k] = v
+	}
+	durations = make(map[time.Duration]int64, len(h.durations))
+	for k, v := range h.durations {
+		durations[k] = v
+	}
+	return values, durations
+}
@@ -0,0 +1,453 @@
+package metrics
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TestScope is a Scope that also exposes a Snapshot of everything
+// reported through it (and through any Scope derived from it via
+// Tagged/SubScope) so far, for use in unit test assertions.
+type TestScope interface {
+	Scope
+
+	// Snapshot returns a point-in-time view of every metric reported
+	// through this scope or any of its descendants.
+	Snapshot() Snapshot
+}
+
+// Snapshot is a typed, read-only view of the counters, gauges, timers
+// and histograms captured by a TestScope.
+type Snapshot interface {
+	// Counters returns every counter snapshot keyed by "name+k=v,k2=v2".
+	Counters() map[string]CounterSnapshot
+
+	// Gauges returns every gauge snapshot keyed by "name+k=v,k2=v2".
+	Gauges() map[string]GaugeSnapshot
+
+	// Timers returns every timer snapshot keyed by "name+k=v,k2=v2".
+	Timers() map[string]TimerSnapshot
+
+	// Histograms returns every histogram snapshot keyed by
+	// "name+k=v,k2=v2".
+	Histograms() map[string]HistogramSnapshot
+
+	// InfoGauges returns every informational gauge snapshot keyed by
+	// "name+k=v,k2=v2".
+	InfoGauges() map[string]InfoGaugeSnapshot
+}
+
+// CounterSnapshot is a point-in-time view of a counter.
+type CounterSnapshot interface {
+	Name() string
+	Tags() map[string]string
+	Value() int64
+}
+
+// GaugeSnapshot is a point-in-time view of a gauge.
+type GaugeSnapshot interface {
+	Name() string
+	Tags() map[string]string
+	Value() float64
+}
+
+// TimerSnapshot is a point-in-time view of every duration a timer has
+// recorded.
+type TimerSnapshot interface {
+	Name() string
+	Tags() map[string]string
+	Values() []time.Duration
+}
+
+// HistogramSnapshot is a point-in-time view of a histogram's recorded
+// value and duration observations, bucketed by upper bound.
+type HistogramSnapshot interface {
+	Name() string
+	Tags() map[string]string
+	Values() map[float64]int64
+	Durations() map[time.Duration]int64
+}
+
+// InfoGaugeSnapshot is a point-in-time view of an informational gauge's
+// key/value payload.
+type InfoGaugeSnapshot interface {
+	Name() string
+	Tags() map[string]string
+	Info() map[string]string
+}
+
+// snapshotKey returns the deterministic "name+k=v,k2=v2" key a metric is
+// recorded and looked up under, with tag keys sorted so the same tag set
+// always produces the same key regardless of insertion order.
+func snapshotKey(name string, tags map[string]string) string {
+	if len(tags) == 0 {
+		return name
+	}
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = k + "=" + tags[k]
+	}
+	return name + "+" + strings.Join(pairs, ",")
+}
+
+// testRegistry is the shared, mutable store backing a root TestScope and
+// every Scope derived from it via Tagged/SubScope.
+type testRegistry struct {
+	mu         sync.Mutex
+	counters   map[string]*testCounter
+	gauges     map[string]*testGauge
+	intGauges  map[string]*testIntGauge
+	infoGauges map[string]*testInfoGauge
+	timers     map[string]*testTimer
+	histograms map[string]*testHistogram
+
+	funcGauges    map[string]*testFuncGauge
+	funcIntGauges map[string]*testFuncIntGauge
+	funcCounters  map[string]*testFuncCounter
+}
+
+func newTestRegistry() *testRegistry {
+	return &testRegistry{
+		counters:      make(map[string]*testCounter),
+		gauges:        make(map[string]*testGauge),
+		intGauges:     make(map[string]*testIntGauge),
+		infoGauges:    make(map[string]*testInfoGauge),
+		timers:        make(map[string]*testTimer),
+		histograms:    make(map[string]*testHistogram),
+		funcGauges:    make(map[string]*testFuncGauge),
+		funcIntGauges: make(map[string]*testFuncIntGauge),
+		funcCounters:  make(map[string]*testFuncCounter),
+	}
+}
+
+// testScope implements TestScope by recording every emission into a
+// shared in-memory testRegistry.
+type testScope struct {
+	registry *testRegistry
+	prefix   string
+	tags     map[string]string
+}
+
+// NewTestScope creates a new root in-memory TestScope. Metrics emitted
+// through it, or through any Scope derived from it via Tagged/SubScope,
+// are collected into a single registry readable via Snapshot.
+func NewTestScope(prefix string, tags map[string]string) TestScope {
+	return &testScope{registry: newTestRegistry(), prefix: prefix, tags: copyStringMap(tags)}
+}
+
+func copyStringMap(m map[string]string) map[string]string {
+	out := make(map[string]string, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
+
+func (s *testScope) fullName(name string) string {
+	if s.prefix == "" {
+		return name
+	}
+	return s.prefix + "." + name
+}
+
+func (s *testScope) key(name string) string {
+	return snapshotKey(s.fullName(name), s.tags)
+}
+
+func (s *testScope) Counter(name string) Counter {
+	k := s.key(name)
+	s.registry.mu.Lock()
+	defer s.registry.mu.Unlock()
+	if c, ok := s.registry.counters[k]; ok {
+		return c
+	}
+	c := &testCounter{name: s.fullName(name), tags: s.tags}
+	s.registry.counters[k] = c
+	return c
+}
+
+func (s *testScope) Gauge(name string) Gauge {
+	k := s.key(name)
+	s.registry.mu.Lock()
+	defer s.registry.mu.Unlock()
+	if g, ok := s.registry.gauges[k]; ok {
+		return g
+	}
+	g := &testGauge{name: s.fullName(name), tags: s.tags}
+	s.registry.gauges[k] = g
+	return g
+}
+
+func (s *testScope) IntegerGauge(name string) IntegerGauge {
+	k := s.key(name)
+	s.registry.mu.Lock()
+	defer s.registry.mu.Unlock()
+	if g, ok := s.registry.intGauges[k]; ok {
+		return g
+	}
+	g := &testIntGauge{name: s.fullName(name), tags: s.tags}
+	s.registry.intGauges[k] = g
+	return g
+}
+
+func (s *testScope) InfoGauge(name string) GaugeInfo {
+	k := s.key(name)
+	s.registry.mu.Lock()
+	defer s.registry.mu.Unlock()
+	if g, ok := s.registry.infoGauges[k]; ok {
+		return g
+	}
+	g := &testInfoGauge{name: s.fullName(name), tags: s.tags}
+	s.registry.infoGauges[k] = g
+	return g
+}
+
+func (s *testScope) FuncGauge(name string, fn func() float64) {
+	k := s.key(name)
+	s.registry.mu.Lock()
+	defer s.registry.mu.Unlock()
+	s.registry.funcGauges[k] = &testFuncGauge{name: s.fullName(name), tags: s.tags, fn: fn}
+}
+
+func (s *testScope) FuncIntGauge(name string, fn func() int64) {
+	k := s.key(name)
+	s.registry.mu.Lock()
+	defer s.registry.mu.Unlock()
+	s.registry.funcIntGauges[k] = &testFuncIntGauge{name: s.fullName(name), tags: s.tags, fn: fn}
+}
+
+func (s *testScope) FuncCounter(name string, fn func() int64) {
+	k := s.key(name)
+	s.registry.mu.Lock()
+	defer s.registry.mu.Unlock()
+	s.registry.funcCounters[k] = &testFuncCounter{name: s.fullName(name), tags: s.tags, fn: fn}
+}
+
+func (s *testScope) Timer(name string) Timer {
+	k := s.key(name)
+	s.registry.mu.Lock()
+	defer s.registry.mu.Unlock()
+	if t, ok := s.registry.timers[k]; ok {
+		return t
+	}
+	t := &testTimer{name: s.fullName(name), tags: s.tags}
+	s.registry.timers[k] = t
+	return t
+}
+
+func (s *testScope) Histogram(name string, buckets Buckets) Histogram {
+	k := s.key(name)
+	s.registry.mu.Lock()
+	defer s.registry.mu.Unlock()
+	if h, ok := s.registry.histograms[k]; ok {
+		return h
+	}
+	h := &testHistogram{name: s.fullName(name), tags: s.tags, buckets: buckets}
+	h.values = make(map[float64]int64)
+	h.durations = make(map[time.Duration]int64)
+	s.registry.histograms[k] = h
+	return h
+}
+
+func (s *testScope) Tagged(tags map[string]string) Scope {
+	merged := copyStringMap(s.tags)
+	for k, v := range tags {
+		merged[k] = v
+	}
+	return &testScope{registry: s.registry, prefix: s.prefix, tags: merged}
+}
+
+func (s *testScope) SubScope(name string) Scope {
+	return &testScope{registry: s.registry, prefix: s.fullName(name), tags: copyStringMap(s.tags)}
+}
+
+func (s *testScope) Capabilities() Capabilities {
+	return testCapabilities{}
+}
+
+type testCapabilities struct{}
+
+func (testCapabilities) Reporting() bool { return true }
+func (testCapabilities) Tagging() bool   { return true }
+
+func (s *testScope) Snapshot() Snapshot {
+	// Copy out the registered metrics under lock, then read/invoke them
+	// once released: a FuncGauge/FuncIntGauge/FuncCounter callback that
+	// itself reports to this scope would otherwise deadlock on the
+	// registry's non-reentrant mutex.
+	s.registry.mu.Lock()
+	counters := make(map[string]*testCounter, len(s.registry.counters))
+	for k, v := range s.registry.counters {
+		counters[k] = v
+	}
+	gauges := make(map[string]*testGauge, len(s.registry.gauges))
+	for k, v := range s.registry.gauges {
+		gauges[k] = v
+	}
+	intGauges := make(map[string]*testIntGauge, len(s.registry.intGauges))
+	for k, v := range s.registry.intGauges {
+		intGauges[k] = v
+	}
+	infoGauges := make(map[string]*testInfoGauge, len(s.registry.infoGauges))
+	for k, v := range s.registry.infoGauges {
+		infoGauges[k] = v
+	}
+	funcGauges := make(map[string]*testFuncGauge, len(s.registry.funcGauges))
+	for k, v := range s.registry.funcGauges {
+		funcGauges[k] = v
+	}
+	funcIntGauges := make(map[string]*testFuncIntGauge, len(s.registry.funcIntGauges))
+	for k, v := range s.registry.funcIntGauges {
+		funcIntGauges[k] = v
+	}
+	funcCounters := make(map[string]*testFuncCounter, len(s.registry.funcCounters))
+	for k, v := range s.registry.funcCounters {
+		funcCounters[k] = v
+	}
+	timers := make(map[string]*testTimer, len(s.registry.timers))
+	for k, v := range s.registry.timers {
+		timers[k] = v
+	}
+	histograms := make(map[string]*testHistogram, len(s.registry.histograms))
+	for k, v := range s.registry.histograms {
+		histograms[k] = v
+	}
+	s.registry.mu.Unlock()
+
+	snap := &snapshot{
+		counters:   make(map[string]CounterSnapshot, len(counters)+len(funcCounters)),
+		gauges:     make(map[string]GaugeSnapshot, len(gauges)+len(intGauges)+len(funcGauges)+len(funcIntGauges)),
+		timers:     make(map[string]TimerSnapshot, len(timers)),
+		histograms: make(map[string]HistogramSnapshot, len(histograms)),
+		infoGauges: make(map[string]InfoGaugeSnapshot, len(infoGauges)),
+	}
+	for k, c := range counters {
+		snap.counters[k] = &counterSnapshot{name: c.name, tags: copyStringMap(c.tags), value: c.Load()}
+	}
+	for k, g := range gauges {
+		snap.gauges[k] = &gaugeSnapshot{name: g.name, tags: copyStringMap(g.tags), value: g.Load()}
+	}
+	for k, g := range intGauges {
+		snap.gauges[k] = &gaugeSnapshot{name: g.name, tags: copyStringMap(g.tags), value: float64(g.Load())}
+	}
+	for k, g := range infoGauges {
+		snap.infoGauges[k] = &infoGaugeSnapshot{name: g.name, tags: copyStringMap(g.tags), info: g.Load()}
+	}
+	for k, g := range funcGauges {
+		if v, ok := callFloatSafe(g.fn); ok {
+			snap.gauges[k] = &gaugeSnapshot{name: g.name, tags: copyStringMap(g.tags), value: v}
+		}
+	}
+	for k, g := range funcIntGauges {
+		if v, ok := callIntSafe(g.fn); ok {
+			snap.gauges[k] = &gaugeSnapshot{name: g.name, tags: copyStringMap(g.tags), value: float64(v)}
+		}
+	}
+	for k, c := range funcCounters {
+		if v, ok := callIntSafe(c.fn); ok {
+			snap.counters[k] = &counterSnapshot{name: c.name, tags: copyStringMap(c.tags), value: v}
+		}
+	}
+	for k, t := range timers {
+		snap.timers[k] = &timerSnapshot{name: t.name, tags: copyStringMap(t.tags), values: t.Load()}
+	}
+	for k, h := range histograms {
+		values, durations := h.Load()
+		snap.histograms[k] = &histogramSnapshot{name: h.name, tags: copyStringMap(h.tags), values: values, durations: durations}
+	}
+	return snap
+}
+
+// callFloatSafe invokes fn with panic recovery, mirroring the behavior
+// real reporters apply to FuncGauge callbacks at report time.
+func callFloatSafe(fn func() float64) (value float64, ok bool) {
+	defer func() {
+		if recover() != nil {
+			ok = false
+		}
+	}()
+	return fn(), true
+}
+
+// callIntSafe is callFloatSafe for callbacks returning an integer.
+func callIntSafe(fn func() int64) (value int64, ok bool) {
+	defer func() {
+		if recover() != nil {
+			ok = false
+		}
+	}()
+	return fn(), true
+}
+
+type snapshot struct {
+	counters   map[string]CounterSnapshot
+	gauges     map[string]GaugeSnapshot
+	timers     map[string]TimerSnapshot
+	histograms map[string]HistogramSnapshot
+	infoGauges map[string]InfoGaugeSnapshot
+}
+
+func (s *snapshot) Counters() map[string]CounterSnapshot     { return s.counters }
+func (s *snapshot) Gauges() map[string]GaugeSnapshot         { return s.gauges }
+func (s *snapshot) Timers() map[string]TimerSnapshot         { return s.timers }
+func (s *snapshot) Histograms() map[string]HistogramSnapshot { return s.histograms }
+func (s *snapshot) InfoGauges() map[string]InfoGaugeSnapshot { return s.infoGauges }
+
+type counterSnapshot struct {
+	name  string
+	tags  map[string]string
+	value int64
+}
+
+func (c *counterSnapshot) Name() string            { return c.name }
+func (c *counterSnapshot) Tags() map[string]string { return c.tags }
+func (c *counterSnapshot) Value() int64            { return c.value }
+
+type gaugeSnapshot struct {
+	name  string
+	tags  map[string]string
+	value float64
+}
+
+func (g *gaugeSnapshot) Name() string            { return g.name }
+func (g *gaugeSnapshot) Tags() map[string]string { return g.tags }
+func (g *gaugeSnapshot) Value() float64          { return g.value }
+
+type timerSnapshot struct {
+	name   string
+	tags   map[string]string
+	values []time.Duration
+}
+
+func (t *timerSnapshot) Name() string            { return t.name }
+func (t *timerSnapshot) Tags() map[string]string { return t.tags }
+func (t *timerSnapshot) Values() []time.Duration { return t.values }
+
+type histogramSnapshot struct {
+	name      string
+	tags      map[string]string
+	values    map[float64]int64
+	durations map[time.Duration]int64
+}
+
+func (h *histogramSnapshot) Name() string                      { return h.name }
+func (h *histogramSnapshot) Tags() map[string]string            { return h.tags }
+func (h *histogramSnapshot) Values() map[float64]int64          { return h.values }
+func (h *histogramSnapshot) Durations() map[time.Duration]int64 { return h.durations }
+
+type infoGaugeSnapshot struct {
+	name string
+	tags map[string]string
+	info map[string]string
+}
+
+func (g *infoGaugeSnapshot) Name() string            { return g.name }
+func (g *infoGaugeSnapshot) Tags() map[string]string { return g.tags }
+func (g *infoGaugeSnapshot) Info() map[string]string { return g.info }
@@ -17,6 +17,27 @@ type Scope interface {
 
 	IntegerGauge(name string) IntegerGauge
 
+	// InfoGauge returns the GaugeInfo object corresponding to the name.
+	// Unlike Gauge, it carries a textual key/value payload rather than a
+	// numeric value, for exposing build/runtime identity (version,
+	// commit, go runtime, OS/arch, ...) alongside numeric telemetry.
+	InfoGauge(name string) GaugeInfo
+
+	// FuncGauge registers fn to be invoked on the scope's reporting tick
+	// and its return value reported as the named gauge, so values like
+	// queue depths or cache sizes can be exposed without a separate
+	// polling goroutine per metric.
+	FuncGauge(name string, fn func() float64)
+
+	// FuncIntGauge is FuncGauge for callbacks returning an integer, e.g.
+	// runtime.NumGoroutine.
+	FuncIntGauge(name string, fn func() int64)
+
+	// FuncCounter registers fn to be invoked on the scope's reporting
+	// tick and its return value reported as the named counter's current
+	// cumulative total.
+	FuncCounter(name string, fn func() int64)
+
 	// Timer returns the Timer object corresponding to the name.
 	Timer(name string) Timer
 
@@ -62,6 +83,14 @@ type IntegerGauge interface {
 	Dec(value int64)
 }
 
+// GaugeInfo is the interface for emitting informational, textual
+// metrics: a set of key/value pairs rather than a single numeric value,
+// e.g. {version="1.2.3", commit="abcd123", go="go1.22", arch="amd64"}.
+type GaugeInfo interface {
+	// Update sets the informational gauge's current key/value payload.
+	Update(info map[string]string)
+}
+
 // Timer is the interface for emitting timer metrics.
 type Timer interface {
 	// Record a specific duration directly.